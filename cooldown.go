@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const userCookieName = "pxg_uid"
+
+var cooldownMs int64
+var cookieSecret []byte
+
+var userMu sync.Mutex
+var lastPlacement map[string]int64
+
+func initCooldown() {
+	cooldownMs, _ = strconv.ParseInt(os.Getenv("COOLDOWN_MS"), 10, 64)
+	cookieSecret = []byte(os.Getenv("COOKIE_SECRET"))
+	loadUsers("storage/users.json")
+}
+
+func signUserID(id string) string {
+	mac := hmac.New(sha256.New, cookieSecret)
+	mac.Write([]byte(id))
+	return id + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+func verifyUserID(signed string) (string, bool) {
+	parts := strings.SplitN(signed, ".", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	if !hmac.Equal([]byte(signUserID(parts[0])), []byte(signed)) {
+		return "", false
+	}
+	return parts[0], true
+}
+
+func newUserID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Fatalln(err.Error())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// identify reads the signed user-id cookie from the request, minting and
+// setting a new one if it is missing or invalid.
+func identify(c *gin.Context) string {
+	if raw, err := c.Cookie(userCookieName); err == nil {
+		if id, ok := verifyUserID(raw); ok {
+			return id
+		}
+	}
+	id := newUserID()
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(userCookieName, signUserID(id), 0, "/", "", false, true)
+	return id
+}
+
+func remainingCooldownMs(id string) int64 {
+	userMu.Lock()
+	defer userMu.Unlock()
+	last, ok := lastPlacement[id]
+	if !ok {
+		return 0
+	}
+	remaining := cooldownMs - (time.Now().UnixMilli() - last)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+func recordPlacement(id string) {
+	userMu.Lock()
+	defer userMu.Unlock()
+	lastPlacement[id] = time.Now().UnixMilli()
+}
+
+func serveCooldown(c *gin.Context) {
+	id := identify(c)
+	c.JSON(200, gin.H{"remainingMs": remainingCooldownMs(id)})
+}
+
+func loadUsers(path string) {
+	lastPlacement = make(map[string]int64)
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		log.Fatalln(err.Error())
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	fileBytes := make([]byte, fileInfo.Size())
+	_, err = file.Read(fileBytes)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if err = json.Unmarshal(fileBytes, &lastPlacement); err != nil {
+		log.Fatalln(err.Error())
+	}
+}
+
+func saveUsers(path string) {
+	userMu.Lock()
+	userBytes, err := json.Marshal(lastPlacement)
+	userMu.Unlock()
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	err = os.WriteFile(path, userBytes, 0644)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+}
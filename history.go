@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultHistoryLimit = 50
+
+// maxHistoryRegionArea bounds the work a single region query can demand,
+// mirroring the x+w/y+h check serveChunk applies to board reads.
+const maxHistoryRegionArea = 1 << 24
+
+// historyMu guards pixelHistoryIndex and placementData, both of which are
+// written by POST /pixel and read by GET /pixel and GET /history from
+// concurrent request goroutines.
+var historyMu sync.RWMutex
+
+// pixelHistoryIndex maps a board position (y*width+x) to the indices in
+// placementData that touched it, giving O(1) per-pixel history lookups.
+// Access must hold historyMu.
+var pixelHistoryIndex = make(map[uint32][]int)
+
+// recordPixelIndex requires the caller to already hold historyMu for writing.
+func recordPixelIndex(x, y, idx int) {
+	key := uint32(y*config.Width + x)
+	pixelHistoryIndex[key] = append(pixelHistoryIndex[key], idx)
+}
+
+// rebuildPixelIndex repopulates pixelHistoryIndex from placementData, used once
+// at startup after loading persisted placements.
+func rebuildPixelIndex() {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	pixelHistoryIndex = make(map[uint32][]int)
+	for i, p := range placementData {
+		recordPixelIndex(p.X, p.Y, i)
+	}
+}
+
+// appendPlacement records a new placement in placementData and its pixel
+// index atomically, so readers never observe one without the other.
+func appendPlacement(p ServerPixel) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	placementData = append(placementData, p)
+	recordPixelIndex(p.X, p.Y, len(placementData)-1)
+}
+
+// findPlacement returns the most recent placement at (x, y), looked up
+// through pixelHistoryIndex, used by GET /pixel.
+func findPlacement(x, y int) (ServerPixel, bool) {
+	historyMu.RLock()
+	defer historyMu.RUnlock()
+	indices := pixelHistoryIndex[uint32(y*config.Width+x)]
+	for i := len(indices) - 1; i >= 0; i-- {
+		if p := placementData[indices[i]]; p.X == x && p.Y == y {
+			return p, true
+		}
+	}
+	return ServerPixel{}, false
+}
+
+// historyFilters is a Docker-style filters DSL: every listed field must
+// match at least one of its values.
+type historyFilters struct {
+	Color  []string `json:"color"`
+	Author []string `json:"author"`
+}
+
+func parseHistoryFilters(raw string) (*historyFilters, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	decoded, err := url.QueryUnescape(raw)
+	if err != nil {
+		return nil, err
+	}
+	var f historyFilters
+	if err := json.Unmarshal([]byte(decoded), &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (f *historyFilters) matches(p ServerPixel) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Color) > 0 && !containsStr(f.Color, strconv.Itoa(p.Color)) {
+		return false
+	}
+	if len(f.Author) > 0 && !containsStr(f.Author, p.Author) {
+		return false
+	}
+	return true
+}
+
+func containsStr(haystack []string, needle string) bool {
+	for _, v := range haystack {
+		if v == needle {
+			return true
+		}
+	}
+	return false
+}
+
+// timeBoundsFull locates [lo, hi) in placementData (which is appended in
+// ascending Time order) covering since..until via binary search. Caller
+// must hold historyMu.
+func timeBoundsFull(since, until int64) (int, int) {
+	lo := 0
+	if since != 0 {
+		lo = sort.Search(len(placementData), func(i int) bool {
+			return placementData[i].Time >= since
+		})
+	}
+	hi := len(placementData)
+	if until != 0 {
+		hi = sort.Search(len(placementData), func(i int) bool {
+			return placementData[i].Time > until
+		})
+	}
+	return lo, hi
+}
+
+// regionIndices unions the per-pixel indices for every pixel in the region,
+// then filters them down to since..until. Caller must hold historyMu.
+func regionIndices(x, y, w, h int, since, until int64) []int {
+	var indices []int
+	for py := y; py < y+h; py++ {
+		for px := x; px < x+w; px++ {
+			for _, idx := range pixelHistoryIndex[uint32(py*config.Width+px)] {
+				t := placementData[idx].Time
+				if since != 0 && t < since {
+					continue
+				}
+				if until != 0 && t > until {
+					continue
+				}
+				indices = append(indices, idx)
+			}
+		}
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		return placementData[indices[i]].Time > placementData[indices[j]].Time
+	})
+	return indices
+}
+
+func serveHistory(c *gin.Context) {
+	hasRegion := false
+	var x, y, w, h int
+	if _, ok := c.GetQuery("x"); ok {
+		hasRegion = true
+		var err error
+		if x, err = strconv.Atoi(c.Query("x")); err != nil {
+			badRequest(c, "x is not a number")
+			return
+		}
+		if y, err = strconv.Atoi(c.Query("y")); err != nil {
+			badRequest(c, "y is not a number")
+			return
+		}
+		if w, err = strconv.Atoi(c.Query("w")); err != nil {
+			badRequest(c, "w is not a number")
+			return
+		}
+		if h, err = strconv.Atoi(c.Query("h")); err != nil {
+			badRequest(c, "h is not a number")
+			return
+		}
+		if x < 0 || y < 0 || w <= 0 || h <= 0 || x+w > config.Width || y+h > config.Height {
+			badRequest(c, "region out of bounds")
+			return
+		}
+		if w*h > maxHistoryRegionArea {
+			badRequest(c, "region too large")
+			return
+		}
+	}
+
+	var since, until int64
+	if raw, ok := c.GetQuery("since"); ok {
+		since, _ = strconv.ParseInt(raw, 10, 64)
+	}
+	if raw, ok := c.GetQuery("until"); ok {
+		until, _ = strconv.ParseInt(raw, 10, 64)
+	}
+
+	filters, err := parseHistoryFilters(c.Query("filters"))
+	if err != nil {
+		badRequest(c, "malformed filters")
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if raw, ok := c.GetQuery("limit"); ok {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			badRequest(c, "limit must be a positive number")
+			return
+		}
+		limit = parsed
+	}
+
+	cursor := 0
+	if raw, ok := c.GetQuery("cursor"); ok {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			badRequest(c, "cursor is invalid")
+			return
+		}
+		cursor = parsed
+	}
+
+	historyMu.RLock()
+	var matched []ServerPixel
+	if hasRegion {
+		for _, idx := range regionIndices(x, y, w, h, since, until) {
+			if filters.matches(placementData[idx]) {
+				matched = append(matched, placementData[idx])
+			}
+		}
+	} else {
+		lo, hi := timeBoundsFull(since, until)
+		for i := hi - 1; i >= lo; i-- {
+			if filters.matches(placementData[i]) {
+				matched = append(matched, placementData[i])
+			}
+		}
+	}
+	historyMu.RUnlock()
+
+	if cursor > len(matched) {
+		cursor = len(matched)
+	}
+	end := cursor + limit
+	if end > len(matched) {
+		end = len(matched)
+	}
+	page := matched[cursor:end]
+
+	resp := gin.H{"results": page}
+	if end < len(matched) {
+		resp["nextCursor"] = strconv.Itoa(end)
+	}
+	c.JSON(200, resp)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestAppendPlacementAndFindPlacement(t *testing.T) {
+	config = &Config{Width: 8, Height: 8}
+	placementData = nil
+	pixelHistoryIndex = make(map[uint32][]int)
+
+	appendPlacement(ServerPixel{X: 1, Y: 2, Color: 3, Time: 100, Author: "a"})
+	appendPlacement(ServerPixel{X: 1, Y: 2, Color: 5, Time: 200, Author: "b"})
+
+	p, ok := findPlacement(1, 2)
+	if !ok || p.Color != 5 {
+		t.Fatalf("expected latest placement at (1,2) with color 5, got %+v ok=%v", p, ok)
+	}
+
+	if _, ok := findPlacement(0, 0); ok {
+		t.Fatalf("expected no placement at (0,0)")
+	}
+
+	indices := regionIndices(0, 0, 8, 8, 0, 0)
+	if len(indices) != 2 {
+		t.Fatalf("expected 2 indices touching (1,2), got %d", len(indices))
+	}
+}
+
+// TestConcurrentAppendAndRegionIndices exercises historyMu under
+// concurrent writers and readers; run with -race to catch regressions.
+func TestConcurrentAppendAndRegionIndices(t *testing.T) {
+	config = &Config{Width: 8, Height: 8}
+	placementData = nil
+	pixelHistoryIndex = make(map[uint32][]int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			appendPlacement(ServerPixel{X: i % 8, Y: i % 8, Color: i % 256, Time: int64(i)})
+		}(i)
+		go func() {
+			defer wg.Done()
+			historyMu.RLock()
+			regionIndices(0, 0, 8, 8, 0, 0)
+			historyMu.RUnlock()
+		}()
+	}
+	wg.Wait()
+}
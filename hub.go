@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	clientSendBuffer  = 16
+	heartbeatInterval = 30 * time.Second
+)
+
+// rect is an optional viewport a client can subscribe to; a nil *rect
+// matches every pixel.
+type rect struct {
+	X, Y, W, H int
+}
+
+func (r *rect) contains(x, y int) bool {
+	if r == nil {
+		return true
+	}
+	return x >= r.X && x < r.X+r.W && y >= r.Y && y < r.Y+r.H
+}
+
+func parseRect(raw string) (*rect, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		return nil, errors.New("rect must be x,y,w,h")
+	}
+	values := make([]int, 4)
+	for i, part := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return &rect{X: values[0], Y: values[1], W: values[2], H: values[3]}, nil
+}
+
+// client is a single websocket subscriber with a bounded outgoing queue; a
+// slow reader gets dropped rather than stalling the hub.
+type client struct {
+	conn *websocket.Conn
+	send chan []byte
+	rect *rect
+}
+
+type hub struct {
+	mu         sync.Mutex
+	clients    map[*client]bool
+	register   chan *client
+	unregister chan *client
+	broadcast  chan ServerPixel
+}
+
+func newHub() *hub {
+	return &hub{
+		clients:    make(map[*client]bool),
+		register:   make(chan *client),
+		unregister: make(chan *client),
+		broadcast:  make(chan ServerPixel, 256),
+	}
+}
+
+func (h *hub) run() {
+	for {
+		select {
+		case c := <-h.register:
+			h.mu.Lock()
+			h.clients[c] = true
+			h.mu.Unlock()
+		case c := <-h.unregister:
+			h.dropClient(c)
+		case pixel := <-h.broadcast:
+			payload, err := json.Marshal(pixel)
+			if err != nil {
+				log.Println(err.Error())
+				continue
+			}
+			h.mu.Lock()
+			for c := range h.clients {
+				if !c.rect.contains(pixel.X, pixel.Y) {
+					continue
+				}
+				select {
+				case c.send <- payload:
+				default:
+					go h.unregisterAsync(c)
+				}
+			}
+			h.mu.Unlock()
+		}
+	}
+}
+
+func (h *hub) dropClient(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		close(c.send)
+	}
+}
+
+func (h *hub) unregisterAsync(c *client) {
+	h.unregister <- c
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+func serveStream(c *gin.Context) {
+	var subscribed *rect
+	if raw, exists := c.GetQuery("rect"); exists {
+		parsed, err := parseRect(raw)
+		if err != nil {
+			badRequest(c, "rect must be x,y,w,h")
+			return
+		}
+		subscribed = parsed
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	cl := &client{
+		conn: conn,
+		send: make(chan []byte, clientSendBuffer),
+		rect: subscribed,
+	}
+	pixelHub.register <- cl
+
+	hello, err := json.Marshal(gin.H{"type": "hello", "config": config})
+	if err == nil {
+		cl.send <- hello
+	}
+
+	go cl.writePump()
+	cl.readPump()
+}
+
+// readPump reads and discards incoming messages, so control frames are
+// processed, and unregisters the client on any error or close. No read
+// deadline is set.
+func (c *client) readPump() {
+	defer func() {
+		pixelHub.unregisterAsync(c)
+		_ = c.conn.Close()
+	}()
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (c *client) writePump() {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer func() {
+		ticker.Stop()
+		_ = c.conn.Close()
+	}()
+	for {
+		select {
+		case message, ok := <-c.send:
+			if !ok {
+				_ = c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestHubDropsSlowClient verifies a client whose send buffer is full gets
+// unregistered instead of blocking the broadcast loop for everyone else.
+func TestHubDropsSlowClient(t *testing.T) {
+	h := newHub()
+	go h.run()
+
+	slow := &client{send: make(chan []byte, clientSendBuffer)}
+	for i := 0; i < clientSendBuffer; i++ {
+		slow.send <- []byte("filler")
+	}
+	fast := &client{send: make(chan []byte, clientSendBuffer)}
+
+	h.register <- slow
+	h.register <- fast
+
+	h.broadcast <- ServerPixel{X: 1, Y: 1, Color: 2}
+
+	select {
+	case msg := <-fast.send:
+		if string(msg) == "" {
+			t.Fatalf("expected a broadcast payload")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("fast client never received the broadcast")
+	}
+
+	deadline := time.After(time.Second)
+	for {
+		h.mu.Lock()
+		_, stillRegistered := h.clients[slow]
+		h.mu.Unlock()
+		if !stillRegistered {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("slow client was never dropped")
+		default:
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
@@ -2,8 +2,6 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
-	"errors"
 	"github.com/gin-gonic/gin"
 	"image"
 	"image/color"
@@ -18,6 +16,10 @@ import (
 	_ "github.com/joho/godotenv/autoload"
 )
 
+// placementBatchInterval is how often queued placements are flushed to
+// storage, bounding how much gets lost in a crash.
+const placementBatchInterval = 250 * time.Millisecond
+
 type Config struct {
 	Width             int      `json:"width"`
 	Height            int      `json:"height"`
@@ -32,22 +34,24 @@ type ClientPixel struct {
 }
 
 type ServerPixel struct {
-	X     int   `json:"x"`
-	Y     int   `json:"y"`
-	Color int   `json:"color"`
-	Time  int64 `json:"time"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Color  int    `json:"color"`
+	Time   int64  `json:"time"`
+	Author string `json:"author"`
 }
 
 var config *Config
 var board []byte
 var placementData []ServerPixel
 var saveTicker *time.Ticker
+var pixelHub *hub
+var placementWrites chan ServerPixel
 
 func main() {
 	log.Printf("PxG v0.1.0")
 	width, _ := strconv.Atoi(os.Getenv("BOARD_WIDTH"))
 	height, _ := strconv.Atoi(os.Getenv("BOARD_HEIGHT"))
-	expectedSize := width * height
 	defaultColorIndex, _ := strconv.Atoi(os.Getenv("DEFAULT_COLOR_INDEX"))
 	palette := strings.Split(os.Getenv("PALETTE"), ",")
 	config = &Config{
@@ -57,16 +61,34 @@ func main() {
 		Palette:           palette,
 	}
 
-	loadCanvas("storage/board.dat", expectedSize, defaultColorIndex)
-	loadPlacementData("storage/data.json")
+	activeStorage = newStorage(os.Getenv("STORAGE_BACKEND"))
+
+	loadedBoard, err := activeStorage.LoadBoard()
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	board = loadedBoard
+
+	placementData, err = activeStorage.QueryPlacements()
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	slices.Reverse(placementData)
+	rebuildPixelIndex()
+	initCooldown()
+
+	pixelHub = newHub()
+	go pixelHub.run()
+
+	placementWrites = make(chan ServerPixel, 1024)
+	go runPlacementWriter(placementWrites)
 
 	saveTicker = time.NewTicker(10 * time.Second)
 	go func() {
 		for {
 			select {
 			case <-saveTicker.C:
-				saveCanvas("storage/board.dat")
-				savePlacementData("storage/data.json")
+				saveUsers("storage/users.json")
 			}
 		}
 	}()
@@ -83,6 +105,15 @@ func main() {
 	r.GET("/board", func(c *gin.Context) {
 		c.Data(200, "application/octet-stream", board)
 	})
+	r.GET("/stream", serveStream)
+	r.GET("/cooldown", serveCooldown)
+	r.GET("/tile/:z/:x/:y", serveTile)
+	r.GET("/chunk", serveChunk)
+	r.GET("/history", serveHistory)
+	r.POST("/templates", serveCreateTemplate)
+	r.GET("/templates", serveListTemplates)
+	r.GET("/templates/:id", serveGetTemplate)
+	r.GET("/templates/:id/progress", serveTemplateProgress)
 	r.GET("/pixel", func(c *gin.Context) {
 		var xQuery string
 		var yQuery string
@@ -91,22 +122,24 @@ func main() {
 		yQuery, exists = c.GetQuery("y")
 		if !exists {
 			c.Status(400)
+			return
 		}
 		x, err := strconv.Atoi(xQuery)
 		if err != nil {
 			badRequest(c, "x is not a number")
+			return
 		}
 		y, err := strconv.Atoi(yQuery)
 		if err != nil {
 			badRequest(c, "y is not a number")
+			return
 		}
-		pixelIndex := slices.IndexFunc(placementData, func(pixel ServerPixel) bool {
-			return pixel.X == x && pixel.Y == y
-		})
-		if pixelIndex == -1 {
+		pixel, ok := findPlacement(x, y)
+		if !ok {
 			c.Status(404)
+			return
 		}
-		c.JSON(200, placementData[pixelIndex])
+		c.JSON(200, pixel)
 	})
 	r.POST("/pixel", func(c *gin.Context) {
 		var clientPlace ClientPixel
@@ -127,14 +160,25 @@ func main() {
 			badRequest(c, "y-coordinate out of range")
 			return
 		}
+		userID := identify(c)
+		if remaining := remainingCooldownMs(userID); remaining > 0 {
+			c.JSON(429, gin.H{"retryAfterMs": remaining})
+			return
+		}
 		board[clientPlace.Y*width+clientPlace.X] = byte(clientPlace.Color)
 		now := time.Now().UnixMilli()
-		placementData = append(placementData, ServerPixel{
-			X:     clientPlace.X,
-			Y:     clientPlace.Y,
-			Color: clientPlace.Color,
-			Time:  now,
-		})
+		placed := ServerPixel{
+			X:      clientPlace.X,
+			Y:      clientPlace.Y,
+			Color:  clientPlace.Color,
+			Time:   now,
+			Author: userID,
+		}
+		appendPlacement(placed)
+		recordPlacement(userID)
+		invalidateTiles(clientPlace.X, clientPlace.Y)
+		placementWrites <- placed
+		pixelHub.broadcast <- placed
 		c.Status(200)
 	})
 	r.GET("/image", func(c *gin.Context) {
@@ -165,74 +209,6 @@ func badRequest(c *gin.Context, message string) {
 	})
 }
 
-func loadCanvas(path string, expectedSize int, defaultColor int) {
-	board = make([]byte, expectedSize)
-	fileInfo, err := os.Stat(path)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			for i := range board {
-				board[i] = byte(defaultColor)
-			}
-		} else {
-			log.Fatalln(err.Error())
-		}
-		return
-	}
-	if fileInfo.Size() != int64(expectedSize) {
-		log.Fatalf("board size (%d) did not match expected size (%d)\n", fileInfo.Size(), expectedSize)
-	}
-	file, err := os.Open(path)
-	if err != nil {
-		log.Fatalln(err.Error())
-	}
-	_, err = file.Read(board)
-	if err != nil {
-		log.Fatalln(err.Error())
-	}
-}
-
-func saveCanvas(path string) {
-	err := os.WriteFile(path, board, 0644)
-	if err != nil {
-		log.Fatalln(err.Error())
-	}
-}
-
-func loadPlacementData(path string) {
-	fileInfo, err := os.Stat(path)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			placementData = make([]ServerPixel, 0)
-		} else {
-			log.Fatalln(err.Error())
-		}
-		return
-	}
-	file, err := os.Open(path)
-	if err != nil {
-		log.Fatalln(err.Error())
-	}
-	fileBytes := make([]byte, fileInfo.Size())
-	_, err = file.Read(fileBytes)
-	if err != nil {
-		log.Fatalln(err.Error())
-	}
-	if err = json.Unmarshal(fileBytes, &placementData); err != nil {
-		log.Fatalln(err.Error())
-	}
-}
-
-func savePlacementData(path string) {
-	placementBytes, err := json.Marshal(placementData)
-	if err != nil {
-		log.Fatalln(err.Error())
-	}
-	err = os.WriteFile(path, placementBytes, 0644)
-	if err != nil {
-		log.Fatalln(err.Error())
-	}
-}
-
 func paletteRGBA(palette []string) []color.RGBA {
 	converted := make([]color.RGBA, len(palette))
 	for i := 0; i < len(palette); i++ {
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Storage abstracts board and placement persistence so the backend can be
+// swapped between the flat-file format and a SQL database without touching
+// request handlers. Placements are appended incrementally rather than
+// rewriting the whole history on every save, so a crash loses nothing past
+// the last flushed batch.
+type Storage interface {
+	LoadBoard() ([]byte, error)
+	SaveBoardDelta(pixels []ServerPixel) error
+	AppendPlacements(pixels []ServerPixel) error
+	// QueryPlacements returns every stored placement, newest first. GET
+	// /history filters placementData and pixelHistoryIndex in memory
+	// (chunk0-4 predates this interface), so this only ever runs once at
+	// startup to hydrate that cache.
+	QueryPlacements() ([]ServerPixel, error)
+}
+
+var activeStorage Storage
+
+// runPlacementWriter drains queued placements in small batches, flushing
+// them to storage roughly every placementBatchInterval instead of
+// rewriting the whole board and history on every tick.
+func runPlacementWriter(writes <-chan ServerPixel) {
+	ticker := time.NewTicker(placementBatchInterval)
+	defer ticker.Stop()
+	var batch []ServerPixel
+	for {
+		select {
+		case p := <-writes:
+			batch = append(batch, p)
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+			if err := activeStorage.AppendPlacements(batch); err != nil {
+				log.Println(err.Error())
+			}
+			if err := activeStorage.SaveBoardDelta(batch); err != nil {
+				log.Println(err.Error())
+			}
+			batch = nil
+		}
+	}
+}
+
+// newStorage picks a backend from STORAGE_BACKEND ("file" by default,
+// "sqlite", or "postgres").
+func newStorage(backend string) Storage {
+	switch backend {
+	case "sqlite":
+		return newSQLiteStorage(os.Getenv("SQLITE_PATH"))
+	case "postgres":
+		return newPostgresStorage(os.Getenv("DATABASE_URL"))
+	default:
+		return newFileStorage("storage/board.dat", "storage/placements.ndjson")
+	}
+}
+
+// fileStorage is the original flat-file backend: a fixed-size byte snapshot
+// of the board, patched byte-by-byte instead of rewritten, and an
+// append-only newline-delimited JSON log of every placement.
+type fileStorage struct {
+	mu        sync.Mutex
+	boardPath string
+	dataPath  string
+}
+
+func newFileStorage(boardPath, dataPath string) *fileStorage {
+	return &fileStorage{boardPath: boardPath, dataPath: dataPath}
+}
+
+func (s *fileStorage) LoadBoard() ([]byte, error) {
+	expectedSize := config.Width * config.Height
+	fileInfo, err := os.Stat(s.boardPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			defaultBoard := make([]byte, expectedSize)
+			for i := range defaultBoard {
+				defaultBoard[i] = byte(config.DefaultColorIndex)
+			}
+			if err := os.WriteFile(s.boardPath, defaultBoard, 0644); err != nil {
+				return nil, err
+			}
+			return defaultBoard, nil
+		}
+		return nil, err
+	}
+	if fileInfo.Size() != int64(expectedSize) {
+		return nil, fmt.Errorf("board size (%d) did not match expected size (%d)", fileInfo.Size(), expectedSize)
+	}
+	return os.ReadFile(s.boardPath)
+}
+
+func (s *fileStorage) SaveBoardDelta(pixels []ServerPixel) error {
+	if len(pixels) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.OpenFile(s.boardPath, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	for _, p := range pixels {
+		offset := int64(p.Y*config.Width + p.X)
+		if _, err := file.WriteAt([]byte{byte(p.Color)}, offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileStorage) AppendPlacements(pixels []ServerPixel) error {
+	if len(pixels) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.OpenFile(s.dataPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	for _, p := range pixels {
+		if err := encoder.Encode(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileStorage) QueryPlacements() ([]ServerPixel, error) {
+	s.mu.Lock()
+	fileBytes, err := os.ReadFile(s.dataPath)
+	s.mu.Unlock()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(fileBytes))
+	var all []ServerPixel
+	for decoder.More() {
+		var p ServerPixel
+		if err := decoder.Decode(&p); err != nil {
+			return nil, err
+		}
+		all = append(all, p)
+	}
+
+	results := make([]ServerPixel, len(all))
+	for i, p := range all {
+		results[len(all)-1-i] = p
+	}
+	return results, nil
+}
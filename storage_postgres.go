@@ -0,0 +1,139 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+
+	_ "github.com/lib/pq"
+)
+
+// postgresStorage is the same schema as sqliteStorage, adapted to
+// Postgres's placeholder syntax and upsert grammar.
+type postgresStorage struct {
+	db *sql.DB
+}
+
+func newPostgresStorage(connStr string) *postgresStorage {
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	s := &postgresStorage{db: db}
+	s.migrate()
+	return s
+}
+
+func (s *postgresStorage) migrate() {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS board_snapshot (id INTEGER PRIMARY KEY, data BYTEA NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS pixels (
+			id BIGSERIAL PRIMARY KEY,
+			x INTEGER NOT NULL,
+			y INTEGER NOT NULL,
+			color INTEGER NOT NULL,
+			time BIGINT NOT NULL,
+			author TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_pixels_xy ON pixels (x, y)`,
+		`CREATE INDEX IF NOT EXISTS idx_pixels_time ON pixels (time)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.Exec(stmt); err != nil {
+			log.Fatalln(err.Error())
+		}
+	}
+
+	expectedSize := config.Width * config.Height
+	defaultBoard := make([]byte, expectedSize)
+	for i := range defaultBoard {
+		defaultBoard[i] = byte(config.DefaultColorIndex)
+	}
+	if _, err := s.db.Exec(`INSERT INTO board_snapshot (id, data) VALUES (1, $1)
+		ON CONFLICT (id) DO NOTHING`, defaultBoard); err != nil {
+		log.Fatalln(err.Error())
+	}
+}
+
+func (s *postgresStorage) LoadBoard() ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM board_snapshot WHERE id = 1`).Scan(&data)
+	if err == sql.ErrNoRows {
+		expectedSize := config.Width * config.Height
+		defaultBoard := make([]byte, expectedSize)
+		for i := range defaultBoard {
+			defaultBoard[i] = byte(config.DefaultColorIndex)
+		}
+		return defaultBoard, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// SaveBoardDelta patches board_snapshot byte-by-byte via set_byte, matching
+// fileStorage's WriteAt approach instead of re-uploading the whole board.
+func (s *postgresStorage) SaveBoardDelta(pixels []ServerPixel) error {
+	if len(pixels) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`UPDATE board_snapshot SET data = set_byte(data, $1, $2) WHERE id = 1`)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, p := range pixels {
+		offset := p.Y*config.Width + p.X
+		if _, err := stmt.Exec(offset, int(p.Color)); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *postgresStorage) AppendPlacements(pixels []ServerPixel) error {
+	if len(pixels) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO pixels (x, y, color, time, author) VALUES ($1, $2, $3, $4, $5)`)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, p := range pixels {
+		if _, err := stmt.Exec(p.X, p.Y, p.Color, p.Time, p.Author); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *postgresStorage) QueryPlacements() ([]ServerPixel, error) {
+	rows, err := s.db.Query(`SELECT x, y, color, time, author FROM pixels ORDER BY time DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ServerPixel
+	for rows.Next() {
+		var p ServerPixel
+		if err := rows.Scan(&p.X, &p.Y, &p.Color, &p.Time, &p.Author); err != nil {
+			return nil, err
+		}
+		results = append(results, p)
+	}
+	return results, rows.Err()
+}
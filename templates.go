@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxTemplateUploadBytes = 5 << 20 // 5 MB
+const templatesDir = "storage/templates"
+
+// Template is the metadata stored alongside a quantized template overlay;
+// the quantized palette-index bytes themselves live in a sibling .bin file.
+type Template struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Author string `json:"author"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	SHA256 string `json:"sha256"`
+}
+
+var templateMu sync.Mutex
+
+func newTemplateID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		log.Fatalln(err.Error())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// quantizePixel returns the palette index whose RGB is closest to c by
+// squared distance.
+func quantizePixel(palette []color.RGBA, c color.RGBA) byte {
+	best := 0
+	bestDist := -1
+	for i, p := range palette {
+		dr := int(p.R) - int(c.R)
+		dg := int(p.G) - int(c.G)
+		db := int(p.B) - int(c.B)
+		dist := dr*dr + dg*dg + db*db
+		if bestDist == -1 || dist < bestDist {
+			bestDist = dist
+			best = i
+		}
+	}
+	return byte(best)
+}
+
+func quantizeImage(img image.Image, palette []color.RGBA) []byte {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	out := make([]byte, w*h)
+	for row := 0; row < h; row++ {
+		for col := 0; col < w; col++ {
+			r, g, b, _ := img.At(bounds.Min.X+col, bounds.Min.Y+row).RGBA()
+			c := color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 0xFF}
+			out[row*w+col] = quantizePixel(palette, c)
+		}
+	}
+	return out
+}
+
+func templatePaths(id string) (metaPath, dataPath string) {
+	return filepath.Join(templatesDir, id+".json"), filepath.Join(templatesDir, id+".bin")
+}
+
+func saveTemplate(t Template, data []byte) error {
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	if err := os.MkdirAll(templatesDir, 0755); err != nil {
+		return err
+	}
+	metaPath, dataPath := templatePaths(t.ID)
+	metaBytes, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(metaPath, metaBytes, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(dataPath, data, 0644)
+}
+
+func loadTemplateMeta(id string) (*Template, error) {
+	metaPath, _ := templatePaths(id)
+	fileBytes, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, err
+	}
+	var t Template
+	if err := json.Unmarshal(fileBytes, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func loadTemplateData(id string) ([]byte, error) {
+	_, dataPath := templatePaths(id)
+	return os.ReadFile(dataPath)
+}
+
+func listTemplates() ([]Template, error) {
+	entries, err := os.ReadDir(templatesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var templates []Template
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		t, err := loadTemplateMeta(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		templates = append(templates, *t)
+	}
+	return templates, nil
+}
+
+func serveCreateTemplate(c *gin.Context) {
+	x, err := strconv.Atoi(c.PostForm("x"))
+	if err != nil {
+		badRequest(c, "x is not a number")
+		return
+	}
+	y, err := strconv.Atoi(c.PostForm("y"))
+	if err != nil {
+		badRequest(c, "y is not a number")
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		badRequest(c, "file is required")
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		badRequest(c, "could not open upload")
+		return
+	}
+	defer file.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(file, maxTemplateUploadBytes+1))
+	if err != nil {
+		badRequest(c, "could not read upload")
+		return
+	}
+	if len(raw) > maxTemplateUploadBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"message": "template exceeds 5 MB upload cap"})
+		return
+	}
+
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		badRequest(c, "not a valid PNG")
+		return
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if x < 0 || y < 0 || x+w > config.Width || y+h > config.Height {
+		badRequest(c, "template would extend past board bounds")
+		return
+	}
+
+	sum := sha256.Sum256(raw)
+	data := quantizeImage(img, paletteRGBA(config.Palette))
+
+	t := Template{
+		ID:     newTemplateID(),
+		Name:   c.PostForm("name"),
+		Author: identify(c),
+		X:      x,
+		Y:      y,
+		Width:  w,
+		Height: h,
+		SHA256: hex.EncodeToString(sum[:]),
+	}
+	if err := saveTemplate(t, data); err != nil {
+		log.Println(err.Error())
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(200, t)
+}
+
+func serveListTemplates(c *gin.Context) {
+	templates, err := listTemplates()
+	if err != nil {
+		log.Println(err.Error())
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(200, templates)
+}
+
+func serveGetTemplate(c *gin.Context) {
+	id := c.Param("id")
+	t, err := loadTemplateMeta(id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	data, err := loadTemplateData(id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.JSON(200, gin.H{
+		"id":     t.ID,
+		"name":   t.Name,
+		"author": t.Author,
+		"x":      t.X,
+		"y":      t.Y,
+		"width":  t.Width,
+		"height": t.Height,
+		"sha256": t.SHA256,
+		"data":   data,
+	})
+}
+
+func serveTemplateProgress(c *gin.Context) {
+	id := c.Param("id")
+	t, err := loadTemplateMeta(id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	data, err := loadTemplateData(id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	total := t.Width * t.Height
+	matching := 0
+	for row := 0; row < t.Height; row++ {
+		for col := 0; col < t.Width; col++ {
+			boardX := t.X + col
+			boardY := t.Y + row
+			if boardX < 0 || boardX >= config.Width || boardY < 0 || boardY >= config.Height {
+				continue
+			}
+			if board[boardY*config.Width+boardX] == data[row*t.Width+col] {
+				matching++
+			}
+		}
+	}
+
+	percent := 0.0
+	if total > 0 {
+		percent = float64(matching) / float64(total) * 100
+	}
+	c.JSON(200, gin.H{
+		"matching": matching,
+		"total":    total,
+		"percent":  percent,
+	})
+}
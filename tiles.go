@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const tileSize = 256
+
+type tileKey struct {
+	Z, X, Y int
+}
+
+// cachedTile holds a rendered tile PNG plus the board region it was drawn
+// from, so a placement can be checked against it to decide whether the
+// tile needs to be redrawn.
+type cachedTile struct {
+	png    []byte
+	etag   string
+	gen    int64
+	dirty  bool
+	region image.Rectangle
+}
+
+var tileMu sync.Mutex
+var tileCache = make(map[tileKey]*cachedTile)
+
+// fitScale is the zoom-0 scale factor that fits the whole board into a
+// single tileSize x tileSize tile.
+func fitScale() float64 {
+	sx := float64(tileSize) / float64(config.Width)
+	sy := float64(tileSize) / float64(config.Height)
+	if sx < sy {
+		return sx
+	}
+	return sy
+}
+
+// zoomScale returns the board-pixels-to-tile-pixels scale at zoom level z,
+// doubling per level and capped at 1:1 native resolution.
+func zoomScale(z int) float64 {
+	scale := fitScale() * math.Pow(2, float64(z))
+	if scale > 1 {
+		scale = 1
+	}
+	return scale
+}
+
+func renderTile(z, x, y int) *cachedTile {
+	scale := zoomScale(z)
+	boardTileSize := float64(tileSize) / scale
+	originX := float64(x) * boardTileSize
+	originY := float64(y) * boardTileSize
+
+	palette := paletteRGBA(config.Palette)
+	img := image.NewRGBA(image.Rect(0, 0, tileSize, tileSize))
+	for py := 0; py < tileSize; py++ {
+		boardY := int(originY + float64(py)/scale)
+		if boardY < 0 || boardY >= config.Height {
+			continue
+		}
+		for px := 0; px < tileSize; px++ {
+			boardX := int(originX + float64(px)/scale)
+			if boardX < 0 || boardX >= config.Width {
+				continue
+			}
+			img.Set(px, py, palette[board[boardY*config.Width+boardX]])
+		}
+	}
+
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+
+	region := image.Rect(int(originX), int(originY), int(math.Ceil(originX+boardTileSize)), int(math.Ceil(originY+boardTileSize)))
+	return &cachedTile{
+		png:    buf.Bytes(),
+		region: region,
+	}
+}
+
+func getTile(z, x, y int) *cachedTile {
+	key := tileKey{Z: z, X: x, Y: y}
+
+	tileMu.Lock()
+	tile, ok := tileCache[key]
+	tileMu.Unlock()
+	if ok && !tile.dirty {
+		return tile
+	}
+
+	fresh := renderTile(z, x, y)
+
+	tileMu.Lock()
+	defer tileMu.Unlock()
+	gen := int64(1)
+	if ok {
+		gen = tile.gen + 1
+	}
+	fresh.gen = gen
+	fresh.etag = fmt.Sprintf(`"%d-%d-%d-%d"`, z, x, y, gen)
+	tileCache[key] = fresh
+	return fresh
+}
+
+// invalidateTiles marks every cached tile whose board region contains the
+// given pixel as dirty, so the next request for it re-renders.
+func invalidateTiles(x, y int) {
+	tileMu.Lock()
+	defer tileMu.Unlock()
+	for _, tile := range tileCache {
+		if tile.dirty {
+			continue
+		}
+		if (image.Point{X: x, Y: y}).In(tile.region) {
+			tile.dirty = true
+		}
+	}
+}
+
+func serveTile(c *gin.Context) {
+	z, err := strconv.Atoi(c.Param("z"))
+	if err != nil {
+		badRequest(c, "z is not a number")
+		return
+	}
+	x, err := strconv.Atoi(c.Param("x"))
+	if err != nil {
+		badRequest(c, "x is not a number")
+		return
+	}
+	y, err := strconv.Atoi(c.Param("y"))
+	if err != nil {
+		badRequest(c, "y is not a number")
+		return
+	}
+	if z < 0 {
+		badRequest(c, "z must be non-negative")
+		return
+	}
+
+	tile := getTile(z, x, y)
+	if match := c.GetHeader("If-None-Match"); match != "" && match == tile.etag {
+		c.Status(304)
+		return
+	}
+	c.Header("ETag", tile.etag)
+	c.Data(200, "image/png", tile.png)
+}
+
+func serveChunk(c *gin.Context) {
+	x, err := strconv.Atoi(c.Query("x"))
+	if err != nil {
+		badRequest(c, "x is not a number")
+		return
+	}
+	y, err := strconv.Atoi(c.Query("y"))
+	if err != nil {
+		badRequest(c, "y is not a number")
+		return
+	}
+	w, err := strconv.Atoi(c.Query("w"))
+	if err != nil {
+		badRequest(c, "w is not a number")
+		return
+	}
+	h, err := strconv.Atoi(c.Query("h"))
+	if err != nil {
+		badRequest(c, "h is not a number")
+		return
+	}
+	if x < 0 || y < 0 || w <= 0 || h <= 0 || x+w > config.Width || y+h > config.Height {
+		badRequest(c, "region out of bounds")
+		return
+	}
+
+	out := make([]byte, w*h)
+	for row := 0; row < h; row++ {
+		srcStart := (y+row)*config.Width + x
+		copy(out[row*w:(row+1)*w], board[srcStart:srcStart+w])
+	}
+	c.Data(200, "application/octet-stream", out)
+}